@@ -0,0 +1,616 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// indexRecord is one entry in an on-disk index: either a file or a
+// directory. For directories, Size holds the aggregate size of the
+// entire subtree rooted there, so a parent total can be recomputed by
+// summing its immediate children without re-walking anything.
+type indexRecord struct {
+	Path  string // relative to the indexed root, "/"-separated
+	Size  int64
+	Mtime int64 // Unix seconds
+	Mode  uint32
+}
+
+func (r indexRecord) isDir() bool {
+	return os.FileMode(r.Mode).IsDir()
+}
+
+// indexFileName is the name of the on-disk database sizer keeps next to
+// a scanned target so later runs can skip re-walking unchanged subtrees.
+const indexFileName = ".sizer.idx"
+
+func indexPathFor(target string) (string, error) {
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(abs, indexFileName), nil
+}
+
+// writeIndexRecord writes one record as:
+//
+//	<uint16 BE name length><name bytes><int64 size><int64 mtime><uint32 mode>
+func writeIndexRecord(w *bufio.Writer, rec indexRecord) error {
+	name := []byte(rec.Path)
+	if len(name) > 0xFFFF {
+		return fmt.Errorf("index: path too long to encode: %s", rec.Path)
+	}
+	var hdr [2]byte
+	binary.BigEndian.PutUint16(hdr[:], uint16(len(name)))
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(name); err != nil {
+		return err
+	}
+	var rest [8 + 8 + 4]byte
+	binary.BigEndian.PutUint64(rest[0:8], uint64(rec.Size))
+	binary.BigEndian.PutUint64(rest[8:16], uint64(rec.Mtime))
+	binary.BigEndian.PutUint32(rest[16:20], rec.Mode)
+	_, err := w.Write(rest[:])
+	return err
+}
+
+// readIndexRecord reads one record written by writeIndexRecord. It
+// returns io.EOF (unwrapped) when the stream is exhausted cleanly.
+func readIndexRecord(r *bufio.Reader) (indexRecord, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return indexRecord{}, err
+	}
+	nameLen := binary.BigEndian.Uint16(hdr[:])
+	name := make([]byte, nameLen)
+	if _, err := io.ReadFull(r, name); err != nil {
+		return indexRecord{}, err
+	}
+	var rest [8 + 8 + 4]byte
+	if _, err := io.ReadFull(r, rest[:]); err != nil {
+		return indexRecord{}, err
+	}
+	return indexRecord{
+		Path:  string(name),
+		Size:  int64(binary.BigEndian.Uint64(rest[0:8])),
+		Mtime: int64(binary.BigEndian.Uint64(rest[8:16])),
+		Mode:  binary.BigEndian.Uint32(rest[16:20]),
+	}, nil
+}
+
+// writeIndex streams records to a temp file in the same directory as
+// path, then renames it into place, so a crash or interrupt mid-write
+// never leaves a truncated index behind.
+func writeIndex(path string, records []indexRecord) (err error) {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, ".sizer.idx.tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	w := bufio.NewWriter(tmp)
+	for _, rec := range records {
+		if err = writeIndexRecord(w, rec); err != nil {
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
+
+func readIndex(path string) ([]indexRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []indexRecord
+	r := bufio.NewReader(f)
+	for {
+		rec, err := readIndexRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// buildIndexRecords walks root depth-first, emitting a record for every
+// file and directory. Children are always emitted before their parent,
+// whose aggregate Size is the sum of its children's sizes. Every record
+// is collected into the returned slice, so the whole tree is held in
+// memory until it is written.
+func buildIndexRecords(root string, showAll bool) ([]indexRecord, error) {
+	var records []indexRecord
+	_, err := walkIndexDir(root, "", showAll, &records)
+	return records, err
+}
+
+func walkIndexDir(absPath, relPath string, showAll bool, records *[]indexRecord) (int64, error) {
+	info, err := os.Lstat(absPath)
+	if err != nil {
+		return 0, err
+	}
+
+	entries, err := os.ReadDir(absPath)
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var total int64
+	for _, entry := range entries {
+		if !showAll && strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		childAbs := filepath.Join(absPath, entry.Name())
+		childRel := entry.Name()
+		if relPath != "" {
+			childRel = relPath + "/" + entry.Name()
+		}
+
+		if entry.IsDir() {
+			size, err := walkIndexDir(childAbs, childRel, showAll, records)
+			if err != nil {
+				continue
+			}
+			total += size
+			continue
+		}
+
+		childInfo, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		*records = append(*records, indexRecord{
+			Path:  childRel,
+			Size:  childInfo.Size(),
+			Mtime: childInfo.ModTime().Unix(),
+			Mode:  uint32(childInfo.Mode()),
+		})
+		total += childInfo.Size()
+	}
+
+	relForSelf := relPath
+	if relForSelf == "" {
+		relForSelf = "."
+	}
+	*records = append(*records, indexRecord{
+		Path:  relForSelf,
+		Size:  total,
+		Mtime: info.ModTime().Unix(),
+		Mode:  uint32(info.Mode()),
+	})
+	return total, nil
+}
+
+// runIndexBuild performs a full walk of target and writes a fresh index.
+func runIndexBuild(target string, showAll bool) error {
+	path, err := indexPathFor(target)
+	if err != nil {
+		return err
+	}
+	records, err := buildIndexRecords(target, showAll)
+	if err != nil {
+		return err
+	}
+	sortIndexRecords(records)
+	return writeIndex(path, records)
+}
+
+// runIndexUpdate re-reads only the directories whose own mtime has
+// changed since the last build, reusing the stored file records of every
+// other directory. Every directory is still stat'd, and every aggregate
+// size is recomputed from its children.
+func runIndexUpdate(target string, showAll bool) error {
+	path, err := indexPathFor(target)
+	if err != nil {
+		return err
+	}
+	old, err := readIndex(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return runIndexBuild(target, showAll)
+		}
+		return err
+	}
+	byPath := make(map[string]indexRecord, len(old))
+	for _, rec := range old {
+		byPath[rec.Path] = rec
+	}
+
+	var fresh []indexRecord
+	if _, err := refreshIndexDir(target, ".", showAll, byPath, &fresh); err != nil {
+		return err
+	}
+	sortIndexRecords(fresh)
+	return writeIndex(path, fresh)
+}
+
+// refreshChild is one direct child of a directory being refreshed,
+// named either from a fresh os.ReadDir or (when the parent's own mtime
+// is unchanged) from the stored records, without touching the disk.
+type refreshChild struct {
+	name  string
+	rel   string
+	isDir bool
+}
+
+// directStoredChildren returns the names of relPath's direct children as
+// recorded in stored, without reading the directory. A directory's
+// mtime only changes when its own direct entries are added, removed, or
+// renamed, so when that mtime matches the last scan, the set of direct
+// children is known to still match what's stored.
+func directStoredChildren(relPath string, stored map[string]indexRecord) []string {
+	var names []string
+	if relPath == "." {
+		for p := range stored {
+			if p == "." || strings.Contains(p, "/") {
+				continue
+			}
+			names = append(names, p)
+		}
+		return names
+	}
+	prefix := relPath + "/"
+	for p := range stored {
+		rest, ok := strings.CutPrefix(p, prefix)
+		if !ok || strings.Contains(rest, "/") {
+			continue
+		}
+		names = append(names, rest)
+	}
+	return names
+}
+
+// refreshIndexDir recomputes relPath's aggregate size, reusing stored
+// records wherever a directory's own mtime shows nothing changed there.
+// Reuse is always scoped to one directory level: an unchanged mtime
+// only means that directory's own direct entries are untouched (per
+// POSIX, a directory's mtime doesn't move when something changes inside
+// a *subdirectory*), so every subdirectory is still recursed into and
+// checked against its own stored mtime independently, all the way down.
+func refreshIndexDir(absPath, relPath string, showAll bool, stored map[string]indexRecord, records *[]indexRecord) (int64, error) {
+	info, err := os.Lstat(absPath)
+	if err != nil {
+		return 0, err
+	}
+
+	old, haveOld := stored[relPath]
+	unchanged := haveOld && old.isDir() && old.Mtime == info.ModTime().Unix()
+
+	var children []refreshChild
+	if unchanged {
+		for _, name := range directStoredChildren(relPath, stored) {
+			if !showAll && strings.HasPrefix(name, ".") {
+				continue
+			}
+			childRel := name
+			if relPath != "." {
+				childRel = relPath + "/" + name
+			}
+			children = append(children, refreshChild{name: name, rel: childRel, isDir: stored[childRel].isDir()})
+		}
+	} else {
+		entries, err := os.ReadDir(absPath)
+		if err != nil {
+			return 0, err
+		}
+		for _, entry := range entries {
+			if !showAll && strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			childRel := entry.Name()
+			if relPath != "." {
+				childRel = relPath + "/" + entry.Name()
+			}
+			children = append(children, refreshChild{name: entry.Name(), rel: childRel, isDir: entry.IsDir()})
+		}
+	}
+
+	var total int64
+	for _, c := range children {
+		childAbs := filepath.Join(absPath, c.name)
+
+		if c.isDir {
+			size, err := refreshIndexDir(childAbs, c.rel, showAll, stored, records)
+			if err != nil {
+				continue
+			}
+			total += size
+			continue
+		}
+
+		if unchanged {
+			// The parent's direct entries are unchanged, so this file's
+			// record (including its own mtime) is still exactly what's
+			// stored; no need to stat it again.
+			if rec, ok := stored[c.rel]; ok {
+				*records = append(*records, rec)
+				total += rec.Size
+				continue
+			}
+		}
+
+		childInfo, err := os.Lstat(childAbs)
+		if err != nil {
+			continue
+		}
+		*records = append(*records, indexRecord{
+			Path:  c.rel,
+			Size:  childInfo.Size(),
+			Mtime: childInfo.ModTime().Unix(),
+			Mode:  uint32(childInfo.Mode()),
+		})
+		total += childInfo.Size()
+	}
+
+	*records = append(*records, indexRecord{
+		Path:  relPath,
+		Size:  total,
+		Mtime: info.ModTime().Unix(),
+		Mode:  uint32(info.Mode()),
+	})
+	return total, nil
+}
+
+// runIndexQuery prints the aggregate size of target (and its immediate
+// children) straight from the on-disk index, without touching the
+// filesystem.
+func runIndexQuery(target string) error {
+	path, err := indexPathFor(target)
+	if err != nil {
+		return err
+	}
+	records, err := readIndex(path)
+	if err != nil {
+		return err
+	}
+
+	byPath := make(map[string]indexRecord, len(records))
+	for _, rec := range records {
+		byPath[rec.Path] = rec
+	}
+
+	root, ok := byPath["."]
+	if !ok {
+		return fmt.Errorf("index: no root record in %s", path)
+	}
+
+	type child struct {
+		name string
+		rec  indexRecord
+	}
+	var children []child
+	for _, rec := range records {
+		if rec.Path == "." || strings.Contains(rec.Path, "/") {
+			continue
+		}
+		children = append(children, child{name: rec.Path, rec: rec})
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].rec.Size > children[j].rec.Size })
+
+	fmt.Printf("\nIndexed contents of: %s\n", target)
+	fmt.Println("----------------------------------------")
+	for _, c := range children {
+		kind := "FILE"
+		if c.rec.isDir() {
+			kind = "DIR"
+		}
+		fmt.Printf("%-6s %-15s %s\n", kind, formatBytes(c.rec.Size), c.name)
+	}
+	fmt.Println("----------------------------------------")
+	fmt.Printf("TOTAL: %s (from index)\n", formatBytes(root.Size))
+	return nil
+}
+
+// runIndexDiff applies an external change list (lines like "M /path",
+// "+ /path", "- /path", e.g. produced by `zfs diff`) to the existing
+// index, patching only the affected records instead of doing a full
+// walk.
+func runIndexDiff(target, changelist string) error {
+	path, err := indexPathFor(target)
+	if err != nil {
+		return err
+	}
+	records, err := readIndex(path)
+	if err != nil {
+		return err
+	}
+	byPath := make(map[string]indexRecord, len(records))
+	for _, rec := range records {
+		byPath[rec.Path] = rec
+	}
+
+	data, err := os.ReadFile(changelist)
+	if err != nil {
+		return err
+	}
+
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		op := line[:1]
+		rest := strings.TrimSpace(line[1:])
+		if rest == "" {
+			continue
+		}
+		rel, err := filepath.Rel(absTarget, rest)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			// Not under target; ignore rather than fail the whole diff.
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		switch op {
+		case "-":
+			old, ok := byPath[rel]
+			if !ok {
+				continue
+			}
+			purgeIndexSubtree(byPath, rel)
+			applyAncestorDelta(byPath, rel, -old.Size)
+		case "+", "M":
+			info, statErr := os.Lstat(rest)
+			if statErr != nil {
+				if old, ok := byPath[rel]; ok {
+					purgeIndexSubtree(byPath, rel)
+					applyAncestorDelta(byPath, rel, -old.Size)
+				}
+				continue
+			}
+			if info.IsDir() {
+				// A diff tool reports a directory line whenever something
+				// changed inside it, alongside the child's own +/-/M line;
+				// its aggregate Size must stay derived from those child
+				// lines via applyAncestorDelta, never overwritten with the
+				// directory inode's own raw size.
+				old, ok := byPath[rel]
+				if !ok {
+					old = indexRecord{Path: rel}
+				}
+				old.Mtime = info.ModTime().Unix()
+				old.Mode = uint32(info.Mode())
+				byPath[rel] = old
+				continue
+			}
+			var oldSize int64
+			if old, ok := byPath[rel]; ok {
+				oldSize = old.Size
+			}
+			newSize := info.Size()
+			byPath[rel] = indexRecord{
+				Path:  rel,
+				Size:  newSize,
+				Mtime: info.ModTime().Unix(),
+				Mode:  uint32(info.Mode()),
+			}
+			applyAncestorDelta(byPath, rel, newSize-oldSize)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	updated := make([]indexRecord, 0, len(byPath))
+	for _, rec := range byPath {
+		updated = append(updated, rec)
+	}
+	sortIndexRecords(updated)
+	return writeIndex(path, updated)
+}
+
+// purgeIndexSubtree removes rel's own record and every stored record
+// under it, using the same rel+"/" prefix match directStoredChildren
+// uses to find a directory's stored children. Without this, deleting a
+// directory from a changelist would leave its descendants' records
+// behind forever, re-persisted on every subsequent write.
+func purgeIndexSubtree(byPath map[string]indexRecord, rel string) {
+	delete(byPath, rel)
+	prefix := rel + "/"
+	for p := range byPath {
+		if strings.HasPrefix(p, prefix) {
+			delete(byPath, p)
+		}
+	}
+}
+
+// applyAncestorDelta adds delta to the stored aggregate Size of rel's
+// every ancestor directory, including the root ("."), creating a
+// directory record for an ancestor that isn't already present. This is
+// how a patched leaf record's size change propagates up to the parent
+// totals a diff is meant to keep correct.
+func applyAncestorDelta(byPath map[string]indexRecord, rel string, delta int64) {
+	if delta == 0 {
+		return
+	}
+	for dir := parentOfRel(rel); ; dir = parentOfRel(dir) {
+		rec, ok := byPath[dir]
+		if !ok {
+			rec = indexRecord{Path: dir, Mode: uint32(os.ModeDir)}
+		}
+		rec.Size += delta
+		byPath[dir] = rec
+		if dir == "." {
+			return
+		}
+	}
+}
+
+// parentOfRel returns the parent of a "/"-separated relative path,
+// rooted at ".".
+func parentOfRel(rel string) string {
+	if rel == "." {
+		return "."
+	}
+	if i := strings.LastIndex(rel, "/"); i >= 0 {
+		return rel[:i]
+	}
+	return "."
+}
+
+func sortIndexRecords(records []indexRecord) {
+	sort.Slice(records, func(i, j int) bool { return records[i].Path < records[j].Path })
+}
+
+// runIndexCmd dispatches `sizer index build|update|query|diff <target>`.
+func runIndexCmd(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: sizer index build|update|query|diff <target> [changelist]")
+	}
+	sub := args[0]
+	target := args[1]
+
+	switch sub {
+	case "build":
+		return runIndexBuild(target, false)
+	case "update":
+		return runIndexUpdate(target, false)
+	case "query":
+		return runIndexQuery(target)
+	case "diff":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: sizer index diff <target> <changelist>")
+		}
+		return runIndexDiff(target, args[2])
+	default:
+		return fmt.Errorf("unknown index subcommand: %s", sub)
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// pin sets path's mtime (and atime) to a deterministic value, so
+// mtime-based reuse decisions in tests don't depend on real clock
+// resolution or how fast the test runs.
+func pin(t *testing.T, path string, at time.Time) {
+	t.Helper()
+	if err := os.Chtimes(path, at, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func recordByPath(t *testing.T, target, relPath string) (indexRecord, bool) {
+	t.Helper()
+	path, err := indexPathFor(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := readIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		if r.Path == relPath {
+			return r, true
+		}
+	}
+	return indexRecord{}, false
+}
+
+func rootSize(t *testing.T, target string) int64 {
+	t.Helper()
+	rec, ok := recordByPath(t, target, ".")
+	if !ok {
+		t.Fatalf("no root record in index for %s", target)
+	}
+	return rec.Size
+}
+
+func TestIndexBuildUpdate(t *testing.T) {
+	t0 := time.Unix(1700000000, 0)
+	t1 := time.Unix(1700000100, 0)
+
+	t.Run("unchanged tree keeps the same root total", func(t *testing.T) {
+		root := t.TempDir()
+		mustMkdir(t, filepath.Join(root, "dirA"))
+		writeFile(t, filepath.Join(root, "dirA", "a.txt"), "hello")
+		pin(t, filepath.Join(root, "dirA", "a.txt"), t0)
+		pin(t, filepath.Join(root, "dirA"), t0)
+		pin(t, root, t0)
+
+		if err := runIndexBuild(root, false); err != nil {
+			t.Fatal(err)
+		}
+		before := rootSize(t, root)
+
+		if err := runIndexUpdate(root, false); err != nil {
+			t.Fatal(err)
+		}
+		after := rootSize(t, root)
+
+		if before != after || before != 5 {
+			t.Fatalf("unchanged tree total drifted: before=%d after=%d, want 5", before, after)
+		}
+	})
+
+	t.Run("changed leaf updates the root total", func(t *testing.T) {
+		root := t.TempDir()
+		writeFile(t, filepath.Join(root, "top.txt"), "hello")
+		pin(t, filepath.Join(root, "top.txt"), t0)
+		pin(t, root, t0)
+
+		if err := runIndexBuild(root, false); err != nil {
+			t.Fatal(err)
+		}
+		if got := rootSize(t, root); got != 5 {
+			t.Fatalf("initial total = %d, want 5", got)
+		}
+
+		writeFile(t, filepath.Join(root, "top.txt"), "hello world!")
+		pin(t, filepath.Join(root, "top.txt"), t1)
+		pin(t, root, t1)
+
+		if err := runIndexUpdate(root, false); err != nil {
+			t.Fatal(err)
+		}
+		if got := rootSize(t, root); got != 12 {
+			t.Fatalf("total after leaf change = %d, want 12", got)
+		}
+	})
+
+	t.Run("change nested two levels deep is picked up without touching ancestors", func(t *testing.T) {
+		root := t.TempDir()
+		mustMkdir(t, filepath.Join(root, "dirB"))
+		mustMkdir(t, filepath.Join(root, "dirB", "sub"))
+		writeFile(t, filepath.Join(root, "dirB", "sub", "orig.txt"), "x")
+		pin(t, filepath.Join(root, "dirB", "sub", "orig.txt"), t0)
+		pin(t, filepath.Join(root, "dirB", "sub"), t0)
+		pin(t, filepath.Join(root, "dirB"), t0)
+		pin(t, root, t0)
+
+		if err := runIndexBuild(root, false); err != nil {
+			t.Fatal(err)
+		}
+		if got := rootSize(t, root); got != 1 {
+			t.Fatalf("initial total = %d, want 1", got)
+		}
+
+		// Add a file two levels down. This moves dirB/sub's own mtime,
+		// but neither dirB's nor root's: adding an entry only touches its
+		// direct parent's mtime, per POSIX.
+		writeFile(t, filepath.Join(root, "dirB", "sub", "new.txt"), "hello world")
+		pin(t, filepath.Join(root, "dirB", "sub", "new.txt"), t1)
+		pin(t, filepath.Join(root, "dirB", "sub"), t1)
+		pin(t, filepath.Join(root, "dirB"), t0) // explicitly left unchanged
+		pin(t, root, t0)                        // explicitly left unchanged
+
+		if err := runIndexUpdate(root, false); err != nil {
+			t.Fatal(err)
+		}
+		if got := rootSize(t, root); got != 12 {
+			t.Fatalf("total after nested change = %d, want 12 (update didn't recurse past an unchanged ancestor)", got)
+		}
+		if rec, ok := recordByPath(t, root, "dirB/sub/new.txt"); !ok || rec.Size != 11 {
+			t.Fatalf("dirB/sub/new.txt record = %+v, ok=%v, want size 11", rec, ok)
+		}
+	})
+}
+
+func mustMkdir(t *testing.T, path string) {
+	t.Helper()
+	if err := os.Mkdir(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeChangelist(t *testing.T, lines ...string) string {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "changelist-*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, line := range lines {
+		if _, err := f.WriteString(line + "\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f.Name()
+}
+
+func TestRunIndexDiff(t *testing.T) {
+	t.Run("+ adds a new file and propagates to the root total", func(t *testing.T) {
+		root := t.TempDir()
+		writeFile(t, filepath.Join(root, "a.txt"), "hello")
+		if err := runIndexBuild(root, false); err != nil {
+			t.Fatal(err)
+		}
+
+		writeFile(t, filepath.Join(root, "b.txt"), "new file")
+		cl := writeChangelist(t, "+ "+filepath.Join(root, "b.txt"))
+		if err := runIndexDiff(root, cl); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := rootSize(t, root); got != 13 {
+			t.Fatalf("total after + = %d, want 13", got)
+		}
+		if rec, ok := recordByPath(t, root, "b.txt"); !ok || rec.Size != 8 {
+			t.Fatalf("b.txt record = %+v, ok=%v, want size 8", rec, ok)
+		}
+	})
+
+	t.Run("M resizes an existing file and propagates the delta", func(t *testing.T) {
+		root := t.TempDir()
+		writeFile(t, filepath.Join(root, "a.txt"), "hello")
+		if err := runIndexBuild(root, false); err != nil {
+			t.Fatal(err)
+		}
+
+		writeFile(t, filepath.Join(root, "a.txt"), "hello world!")
+		cl := writeChangelist(t, "M "+filepath.Join(root, "a.txt"))
+		if err := runIndexDiff(root, cl); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := rootSize(t, root); got != 12 {
+			t.Fatalf("total after M = %d, want 12", got)
+		}
+	})
+
+	t.Run("- removes a file and propagates the delta", func(t *testing.T) {
+		root := t.TempDir()
+		writeFile(t, filepath.Join(root, "a.txt"), "hello")
+		writeFile(t, filepath.Join(root, "b.txt"), "world!")
+		if err := runIndexBuild(root, false); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.Remove(filepath.Join(root, "b.txt")); err != nil {
+			t.Fatal(err)
+		}
+		cl := writeChangelist(t, "- "+filepath.Join(root, "b.txt"))
+		if err := runIndexDiff(root, cl); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := rootSize(t, root); got != 5 {
+			t.Fatalf("total after - = %d, want 5", got)
+		}
+		if _, ok := recordByPath(t, root, "b.txt"); ok {
+			t.Fatalf("b.txt record still present after -")
+		}
+	})
+
+	t.Run("- on a directory purges every descendant record", func(t *testing.T) {
+		root := t.TempDir()
+		mustMkdir(t, filepath.Join(root, "sub"))
+		writeFile(t, filepath.Join(root, "sub", "a.txt"), "hello")
+		writeFile(t, filepath.Join(root, "sub", "b.txt"), "world!")
+		if err := runIndexBuild(root, false); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.RemoveAll(filepath.Join(root, "sub")); err != nil {
+			t.Fatal(err)
+		}
+		cl := writeChangelist(t, "- "+filepath.Join(root, "sub"))
+		if err := runIndexDiff(root, cl); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := rootSize(t, root); got != 0 {
+			t.Fatalf("total after directory - = %d, want 0", got)
+		}
+		for _, p := range []string{"sub", "sub/a.txt", "sub/b.txt"} {
+			if _, ok := recordByPath(t, root, p); ok {
+				t.Fatalf("%s record still present after deleting its directory", p)
+			}
+		}
+	})
+
+	t.Run("M on a directory line alongside its child's own + doesn't clobber the aggregate", func(t *testing.T) {
+		root := t.TempDir()
+		mustMkdir(t, filepath.Join(root, "sub"))
+		writeFile(t, filepath.Join(root, "sub", "a.txt"), "hello")
+		if err := runIndexBuild(root, false); err != nil {
+			t.Fatal(err)
+		}
+
+		writeFile(t, filepath.Join(root, "sub", "b.txt"), "world!!!!!!!")
+		cl := writeChangelist(t,
+			"+ "+filepath.Join(root, "sub", "b.txt"),
+			"M "+filepath.Join(root, "sub"),
+		)
+		if err := runIndexDiff(root, cl); err != nil {
+			t.Fatal(err)
+		}
+
+		if rec, ok := recordByPath(t, root, "sub"); !ok || rec.Size != 17 {
+			t.Fatalf("sub record = %+v, ok=%v, want aggregate size 17", rec, ok)
+		}
+		if got := rootSize(t, root); got != 17 {
+			t.Fatalf("total after nested M/+ = %d, want 17", got)
+		}
+	})
+
+	t.Run("+ under a nested subdirectory propagates through every ancestor", func(t *testing.T) {
+		root := t.TempDir()
+		mustMkdir(t, filepath.Join(root, "dirB"))
+		mustMkdir(t, filepath.Join(root, "dirB", "sub"))
+		writeFile(t, filepath.Join(root, "dirB", "sub", "orig.txt"), "x")
+		if err := runIndexBuild(root, false); err != nil {
+			t.Fatal(err)
+		}
+
+		writeFile(t, filepath.Join(root, "dirB", "sub", "new.txt"), "hello world")
+		cl := writeChangelist(t, "+ "+filepath.Join(root, "dirB", "sub", "new.txt"))
+		if err := runIndexDiff(root, cl); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := rootSize(t, root); got != 12 {
+			t.Fatalf("total after nested + = %d, want 12", got)
+		}
+		if rec, ok := recordByPath(t, root, "dirB"); !ok || rec.Size != 12 {
+			t.Fatalf("dirB record = %+v, ok=%v, want aggregate size 12", rec, ok)
+		}
+		if rec, ok := recordByPath(t, root, "dirB/sub"); !ok || rec.Size != 12 {
+			t.Fatalf("dirB/sub record = %+v, ok=%v, want aggregate size 12", rec, ok)
+		}
+	})
+}
@@ -1,51 +1,108 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io/fs"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"runtime"
-	"sort"
-	"sync"
-	"time"
+	"strconv"
+	"syscall"
+
+	"github.com/eli-rich/sizer/pkg/scan"
 )
 
-type Entry struct {
-	Name string
-	Size int64
-	Type string // "file" or "dir"
-}
+func formatBytes(b int64) string { return formatSize(b, false) }
 
-func formatBytes(b int64) string {
-	const unit = 1024
+// formatSize renders b using IEC (1024-based, the sizer default) or SI
+// (1000-based, matching `du`/`ls -h --si`) units.
+func formatSize(b int64, si bool) string {
+	unit := int64(1024)
+	suffix := "KMGTPE"
+	if si {
+		unit = 1000
+	}
 	if b < unit {
 		return fmt.Sprintf("%d B", b)
 	}
-	div, exp := int64(unit), 0
+	div, exp := unit, 0
 	for n := b / unit; n >= unit; n /= unit {
 		div *= unit
 		exp++
 	}
-	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
+	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), suffix[exp])
 }
 
 func main() {
+	// `sizer index ...` is a separate subsystem for persisted, incrementally
+	// updated scans; dispatch to it before the normal flag parsing below.
+	if len(os.Args) > 1 && os.Args[1] == "index" {
+		if err := runIndexCmd(os.Args[2:]); err != nil {
+			log.Fatalf("error: %v\n", err)
+		}
+		return
+	}
+
 	// Parse arguments manually to allow flexible ordering
 	var target string
 	var showAll bool
-
-	// Look for the -a flag anywhere in arguments
-	for _, arg := range os.Args[1:] {
-		if arg == "-a" {
+	var si bool
+	var depth, top int
+	format := "text"
+
+	args := os.Args[1:]
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-a":
 			showAll = true
-		} else if len(target) == 0 {
-			// First non-flag argument is the target
+		case arg == "-H" || arg == "--si":
+			si = true
+		case arg == "-o":
+			if i+1 >= len(args) {
+				log.Fatalf("error: -o requires a value (text, json, ndjson, csv, tree)\n")
+			}
+			format = args[i+1]
+			i++
+		case arg == "--depth":
+			if i+1 >= len(args) {
+				log.Fatalf("error: --depth requires a value\n")
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 {
+				log.Fatalf("error: --depth wants a positive integer, got %q\n", args[i+1])
+			}
+			depth = n
+			i++
+		case arg == "--top":
+			if i+1 >= len(args) {
+				log.Fatalf("error: --top requires a value\n")
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 {
+				log.Fatalf("error: --top wants a positive integer, got %q\n", args[i+1])
+			}
+			top = n
+			i++
+		case len(target) == 0:
 			target = arg
 		}
 	}
 
+	switch format {
+	case "text", "json", "ndjson", "csv", "tree":
+	default:
+		log.Fatalf("error: unknown format %q (want text, json, ndjson, csv, tree)\n", format)
+	}
+	if format == "tree" && top > 0 {
+		// renderTree prints nesting as-is with no notion of "largest
+		// anywhere"; silently falling back to a flat renderer would drop
+		// the tree format the user asked for without saying so.
+		log.Fatalf("error: -o tree doesn't support --top; use --depth to bound how deep the tree goes\n")
+	}
+
 	// Check if we have a target
 	if target == "" {
 		target = "."
@@ -67,15 +124,15 @@ func main() {
 			absPath = target // Fallback to target if we can't get absolute path
 		}
 		fmt.Printf("\nFile: %s\n", absPath)
-		fmt.Printf("Size: %s\n", formatBytes(fileSize))
+		fmt.Printf("Size: %s\n", formatSize(fileSize, si))
 		return
 	}
 
-	// If target is a directory, proceed with normal directory analysis
-	rootEntries, totalSize, totalCount, err := listRootWithSizes(target, showAll)
-	if err != nil {
-		log.Fatalf("error walking: %v\n", err)
-	}
+	// Ctrl-C cancels the scan instead of tearing it down mid-write: the
+	// context is threaded through to every directory boundary, so the
+	// scanner unwinds promptly and we can report a clean error.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Get absolute path for display
 	absPath, err := filepath.Abs(target)
@@ -83,187 +140,66 @@ func main() {
 		absPath = target // Fallback to target if we can't get absolute path
 	}
 
-	// Print each root entry with its size
-	fmt.Printf("\nContents of: %s\n", absPath)
-	fmt.Println("----------------------------------------")
-	for _, entry := range rootEntries {
-		entryType := "DIR"
-		if entry.Type == "file" {
-			entryType = "FILE"
-		}
-		fmt.Printf("%-6s %-15s %s\n", entryType, formatBytes(entry.Size), entry.Name)
+	// --top with no --depth searches the whole tree ("largest anywhere");
+	// otherwise --depth bounds how many levels get expanded. With neither
+	// flag, -o tree expands defaultTreeDepth levels and every other
+	// format needs a single level.
+	scanDepth := depth
+	switch {
+	case scanDepth == 0 && top > 0:
+		scanDepth = -1
+	case scanDepth == 0 && format == "tree":
+		scanDepth = defaultTreeDepth
+	case scanDepth == 0:
+		scanDepth = 1
 	}
-	fmt.Println("----------------------------------------")
-	fmt.Printf("TOTAL: %s (%d files)\n", formatBytes(totalSize), totalCount)
-}
-
-func listRootWithSizes(root string, showAll bool) ([]Entry, int64, int, error) {
-	var entries []Entry
-	var totalSize int64 = 0
-	var totalCount int = 0
 
-	// Read the root directory
-	dirEntries, err := os.ReadDir(root)
-	if err != nil {
-		return nil, 0, 0, err
+	opts := scan.Options{ShowAll: showAll}
+	if format == "ndjson" && scanDepth == 1 && top == 0 {
+		// Stream one JSON object per entry as it's discovered, instead of
+		// buffering the whole result set. With --depth/--top the final
+		// list isn't known until the whole tree is in, so it's printed
+		// once scanning finishes instead.
+		opts.OnEntry = printNDJSONEntry
+	} else if format == "text" {
+		// Progress text is only meaningful for the interactive, human-
+		// readable format; every other format is meant to be piped into
+		// something like jq, so stray "\rScanning:" bytes would corrupt it.
+		opts.OnScanning = func(name string) { fmt.Fprintf(os.Stderr, "\rScanning: %-30s", name) }
+		opts.OnProgress = func(done, total int) { fmt.Fprintf(os.Stderr, "\rScanned %d/%d directories...", done, total) }
 	}
 
-	// Filter out dotfiles and prepare directories for parallel processing
-	var dirs []os.DirEntry
-	for _, dirEntry := range dirEntries {
-		// Skip dotfiles (hidden files/directories) unless showAll is true
-		if !showAll && len(dirEntry.Name()) > 0 && dirEntry.Name()[0] == '.' {
-			continue
-		}
-
-		if dirEntry.IsDir() {
-			dirs = append(dirs, dirEntry)
-		} else {
-			// Process files immediately
-			info, err := dirEntry.Info()
-			if err != nil {
-				continue // Skip files we can't get info for
-			}
-
-			entries = append(entries, Entry{
-				Name: dirEntry.Name(),
-				Size: info.Size(),
-				Type: "file",
-			})
-
-			totalSize += info.Size()
-			totalCount++
-		}
+	scanner := scan.NewScanner(nil)
+	tree, err := scanner.ScanTree(ctx, target, opts, scanDepth)
+	if opts.OnScanning != nil {
+		fmt.Fprint(os.Stderr, "\033[2K\r") // Clear the "Scanning" line
 	}
-
-	// Process directories in parallel for large directory sets
-	if len(dirs) > 0 {
-		// Create a result channel
-		type dirResult struct {
-			entry Entry
-			size  int64
-			count int
-			err   error
-		}
-
-		// Use number of CPUs but cap at a reasonable maximum
-		numWorkers := runtime.NumCPU()
-		if numWorkers > 8 {
-			numWorkers = 8
-		}
-
-		// Create channels for work distribution
-		results := make(chan dirResult, len(dirs))
-		var wg sync.WaitGroup
-
-		// Start the worker pool
-		dirCh := make(chan os.DirEntry, len(dirs))
-		for i := 0; i < numWorkers; i++ {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				for dirEntry := range dirCh {
-					entryPath := filepath.Join(root, dirEntry.Name())
-
-					// Show scanning progress
-					fmt.Printf("\rScanning: %-30s", dirEntry.Name())
-
-					// Calculate directory size
-					size, count, err := sizeDir(entryPath, showAll)
-
-					results <- dirResult{
-						entry: Entry{
-							Name: dirEntry.Name(),
-							Size: size,
-							Type: "dir",
-						},
-						size:  size,
-						count: count,
-						err:   err,
-					}
-				}
-			}()
-		}
-
-		// Feed directories to workers
-		for _, dir := range dirs {
-			dirCh <- dir
-		}
-		close(dirCh)
-
-		// Wait for all workers to complete
-		go func() {
-			wg.Wait()
-			close(results)
-		}()
-
-		// Process results
-		var lastUpdate time.Time
-		resultsProcessed := 0
-		for result := range results {
-			resultsProcessed++
-
-			// Update progress every 100ms
-			now := time.Now()
-			if now.Sub(lastUpdate) > 100*time.Millisecond {
-				fmt.Printf("\rScanned %d/%d directories...", resultsProcessed, len(dirs))
-				lastUpdate = now
-			}
-
-			if result.err != nil {
-				continue
-			}
-
-			entries = append(entries, result.entry)
-			totalSize += result.size
-			totalCount += result.count
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			log.Fatalf("scan cancelled\n")
 		}
+		log.Fatalf("error walking: %v\n", err)
 	}
 
-	// Sort entries by size (largest first)
-	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].Size > entries[j].Size
-	})
-
-	// Clear the "Scanning" line
-	fmt.Print("\033[2K\r")
-
-	return entries, totalSize, totalCount, nil
-}
-
-func sizeDir(root string, showAll bool) (int64, int, error) {
-	var total int64 = 0
-	count := 0
-	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil
-		}
-
-		// Skip dotfiles/directories unless showAll is true
-		name := filepath.Base(path)
-		if !showAll && len(name) > 0 && name[0] == '.' {
-			if d.IsDir() {
-				return filepath.SkipDir // Skip entire directory
-			}
-			return nil // Skip file
-		}
-
-		if d.IsDir() {
-			return nil
-		}
-
-		if d.Type()&os.ModeSymlink != 0 {
-			count++
-			return nil
-		}
+	if format == "tree" {
+		renderTree(absPath, tree, si)
+		return
+	}
 
-		info, err := d.Info()
-		if err != nil {
-			return nil
+	rows := flattenForOutput(tree, depth, top)
+	switch format {
+	case "json":
+		renderJSON(absPath, rows, tree.Size, tree.Count)
+	case "ndjson":
+		if opts.OnEntry == nil {
+			// Entries weren't streamed live (--depth/--top need the full
+			// tree before the row list is known), so print them now.
+			printNDJSONRows(rows)
 		}
-		total += info.Size()
-		count++
-		return nil
-	})
-	return total, count, err
+		printNDJSONTotal(tree.Size, tree.Count)
+	case "csv":
+		renderCSV(rows)
+	default:
+		renderText(absPath, rows, tree.Size, tree.Count, si)
+	}
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eli-rich/sizer/pkg/scan"
+)
+
+// defaultTreeDepth bounds how deep `-o tree` recurses when the user
+// hasn't also given --depth.
+const defaultTreeDepth = 3
+
+// outRow is one line of flat output: either a root's direct child (the
+// original, default behavior) or a node from anywhere in a
+// --depth/--top expanded tree, in which case Path is relative to the
+// scanned root instead of being just a bare name.
+type outRow struct {
+	Path string
+	Size int64
+	Type string
+}
+
+// flattenForOutput turns tree into the rows a renderer prints. With
+// neither --depth nor --top set, this is exactly the old one-level
+// behavior: tree's direct children, sorted largest-first. Once
+// either is set, every expanded node is a candidate row, addressed by
+// its path relative to root so callers can tell where in the tree it
+// lives.
+func flattenForOutput(tree *scan.Node, depth, top int) []outRow {
+	if depth <= 1 && top <= 0 {
+		rows := make([]outRow, 0, len(tree.Children))
+		for _, c := range tree.Children {
+			rows = append(rows, outRow{Path: c.Name, Size: c.Size, Type: c.Type})
+		}
+		sort.Slice(rows, func(i, j int) bool { return rows[i].Size > rows[j].Size })
+		return rows
+	}
+
+	var rows []outRow
+	for _, n := range tree.Flatten() {
+		if n == tree {
+			continue // the root itself isn't a row
+		}
+		rows = append(rows, outRow{Path: relPath(tree.Path, n.Path), Size: n.Size, Type: n.Type})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Size > rows[j].Size })
+	if top > 0 && len(rows) > top {
+		rows = rows[:top]
+	}
+	return rows
+}
+
+func relPath(root, path string) string {
+	if rel := strings.TrimPrefix(path, root); rel != path {
+		return strings.TrimPrefix(rel, "/")
+	}
+	return path
+}
+
+// renderText is sizer's original human-readable output.
+func renderText(absPath string, rows []outRow, totalSize int64, totalCount int, si bool) {
+	fmt.Printf("\nContents of: %s\n", absPath)
+	fmt.Println("----------------------------------------")
+	for _, row := range rows {
+		entryType := "DIR"
+		if row.Type == "file" {
+			entryType = "FILE"
+		}
+		fmt.Printf("%-6s %-15s %s\n", entryType, formatSize(row.Size, si), row.Path)
+	}
+	fmt.Println("----------------------------------------")
+	fmt.Printf("TOTAL: %s (%d files)\n", formatSize(totalSize, si), totalCount)
+}
+
+type jsonEntry struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	Type string `json:"type"`
+}
+
+type jsonResult struct {
+	Path       string      `json:"path"`
+	TotalSize  int64       `json:"total_size"`
+	TotalCount int         `json:"total_count"`
+	Entries    []jsonEntry `json:"entries"`
+}
+
+func renderJSON(absPath string, rows []outRow, totalSize int64, totalCount int) {
+	out := jsonResult{Path: absPath, TotalSize: totalSize, TotalCount: totalCount}
+	for _, row := range rows {
+		out.Entries = append(out.Entries, jsonEntry{Name: row.Path, Size: row.Size, Type: row.Type})
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		log.Fatalf("error encoding json: %v\n", err)
+	}
+}
+
+func renderCSV(rows []outRow) {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"type", "size", "name"})
+	for _, row := range rows {
+		w.Write([]string{row.Type, strconv.FormatInt(row.Size, 10), row.Path})
+	}
+	w.Flush()
+}
+
+// ndjsonLine is one line of NDJSON output: either a "record":"entry" as
+// each entry is discovered, or a final "record":"total" summary.
+type ndjsonLine struct {
+	Record string `json:"record"`
+	Name   string `json:"name,omitempty"`
+	Size   int64  `json:"size"`
+	Type   string `json:"type,omitempty"`
+	Count  int    `json:"count,omitempty"`
+}
+
+func printNDJSONEntry(e scan.Entry) {
+	json.NewEncoder(os.Stdout).Encode(ndjsonLine{Record: "entry", Name: e.Name, Size: e.Size, Type: e.Type})
+}
+
+func printNDJSONRows(rows []outRow) {
+	for _, row := range rows {
+		json.NewEncoder(os.Stdout).Encode(ndjsonLine{Record: "entry", Name: row.Path, Size: row.Size, Type: row.Type})
+	}
+}
+
+func printNDJSONTotal(totalSize int64, totalCount int) {
+	json.NewEncoder(os.Stdout).Encode(ndjsonLine{Record: "total", Size: totalSize, Count: totalCount})
+}
+
+// renderTree prints an indented recursive view with cumulative sizes
+// per directory, similar to `du -h --max-depth=N` combined with `tree`.
+// Unlike the flat renderers, it walks tree.Children directly rather
+// than a flattened row list, since the nesting itself is the point.
+func renderTree(absPath string, tree *scan.Node, si bool) {
+	fmt.Printf("%s (%s)\n", absPath, formatSize(tree.Size, si))
+	printTreeChildren(tree.Children, 1, si)
+}
+
+func printTreeChildren(children []*scan.Node, depth int, si bool) {
+	indent := strings.Repeat("  ", depth)
+	for _, c := range children {
+		fmt.Printf("%s%s %s\n", indent, formatSize(c.Size, si), c.Name)
+		if len(c.Children) > 0 {
+			printTreeChildren(c.Children, depth+1, si)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package scan
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Filesystem abstracts the handful of filesystem operations scanning
+// needs, so a scan can target something other than the local OS (an
+// archive, a remote tree, an in-memory fixture) without touching
+// Pipeline, ScanTree or sizeDirWith.
+type Filesystem interface {
+	Lstat(path string) (os.FileInfo, error)
+	ReadDir(path string) ([]os.DirEntry, error)
+	// Walk mirrors filepath.WalkDir, but checks ctx before descending
+	// into each entry so a long walk can be cancelled cleanly.
+	Walk(ctx context.Context, root string, fn fs.WalkDirFunc) error
+	Join(elem ...string) string
+}
+
+// OSFilesystem is the default Filesystem, backed directly by the os and
+// path/filepath packages. This is the behavior sizer has always had.
+type OSFilesystem struct{}
+
+func (OSFilesystem) Lstat(path string) (os.FileInfo, error) { return os.Lstat(path) }
+
+func (OSFilesystem) ReadDir(path string) ([]os.DirEntry, error) { return os.ReadDir(path) }
+
+func (OSFilesystem) Join(elem ...string) string { return filepath.Join(elem...) }
+
+func (OSFilesystem) Walk(ctx context.Context, root string, fn fs.WalkDirFunc) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fn(path, d, err)
+	})
+}
@@ -0,0 +1,87 @@
+package scan
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// TarFilesystem reports sizes of entries inside a tar archive (optionally
+// gzip-compressed) without extracting them: the archive is scanned once
+// at open time to build an in-memory index of headers, and every
+// subsequent Lstat/ReadDir/Walk call is served from that index.
+type TarFilesystem struct {
+	*indexedFilesystem
+}
+
+func OpenTarFilesystem(archivePath string) (*TarFilesystem, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(archivePath, ".gz") || strings.HasSuffix(archivePath, ".tgz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	idx := newIndexedFilesystem()
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		mode := fs.FileMode(hdr.Mode & 0o777)
+		if hdr.Typeflag == tar.TypeDir {
+			mode |= fs.ModeDir
+		}
+		idx.put(hdr.Name, indexedEntry{
+			size:    hdr.Size,
+			mode:    mode,
+			modTime: hdr.ModTime,
+		})
+	}
+	return &TarFilesystem{indexedFilesystem: idx}, nil
+}
+
+// ZipFilesystem reports sizes of entries inside a zip archive without
+// extracting them, using the archive's central directory for instant
+// random access to per-entry metadata.
+type ZipFilesystem struct {
+	*indexedFilesystem
+	closer io.Closer
+}
+
+func OpenZipFilesystem(archivePath string) (*ZipFilesystem, error) {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := newIndexedFilesystem()
+	for _, f := range zr.File {
+		mode := f.Mode()
+		idx.put(f.Name, indexedEntry{
+			size:    int64(f.UncompressedSize64),
+			mode:    mode,
+			modTime: f.Modified,
+		})
+	}
+	return &ZipFilesystem{indexedFilesystem: idx, closer: zr}, nil
+}
+
+func (z *ZipFilesystem) Close() error { return z.closer.Close() }
@@ -0,0 +1,153 @@
+package scan
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"time"
+)
+
+// indexedEntry describes one path in an indexedFilesystem.
+type indexedEntry struct {
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// indexedFilesystem is a Filesystem backed entirely by an in-memory map
+// of path -> indexedEntry. MemFilesystem, TarFilesystem and
+// ZipFilesystem are all thin constructors around this: they differ only
+// in how the map gets populated.
+type indexedFilesystem struct {
+	entries map[string]indexedEntry
+}
+
+func newIndexedFilesystem() *indexedFilesystem {
+	f := &indexedFilesystem{entries: map[string]indexedEntry{}}
+	f.entries["/"] = indexedEntry{mode: fs.ModeDir, modTime: time.Now()}
+	return f
+}
+
+// put records path p, creating any missing ancestor directories so
+// ReadDir on them works even if the source archive never listed them
+// explicitly (tar archives commonly omit directory entries).
+func (f *indexedFilesystem) put(p string, e indexedEntry) {
+	p = f.clean(p)
+	f.entries[p] = e
+
+	for dir := path.Dir(p); dir != "/" && dir != "."; dir = path.Dir(dir) {
+		dir = f.clean(dir)
+		if _, ok := f.entries[dir]; ok {
+			continue
+		}
+		f.entries[dir] = indexedEntry{mode: fs.ModeDir, modTime: e.modTime}
+	}
+	if _, ok := f.entries["/"]; !ok {
+		f.entries["/"] = indexedEntry{mode: fs.ModeDir, modTime: e.modTime}
+	}
+}
+
+func (f *indexedFilesystem) clean(p string) string {
+	p = path.Clean("/" + p)
+	return p
+}
+
+func (f *indexedFilesystem) Lstat(p string) (os.FileInfo, error) {
+	p = f.clean(p)
+	e, ok := f.entries[p]
+	if !ok {
+		return nil, &os.PathError{Op: "lstat", Path: p, Err: os.ErrNotExist}
+	}
+	return indexedFileInfo{name: path.Base(p), entry: e}, nil
+}
+
+func (f *indexedFilesystem) ReadDir(p string) ([]os.DirEntry, error) {
+	p = f.clean(p)
+	if e, ok := f.entries[p]; !ok || !e.mode.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: p, Err: os.ErrNotExist}
+	}
+
+	var out []os.DirEntry
+	for candidate, e := range f.entries {
+		if candidate == p {
+			continue
+		}
+		if path.Dir(candidate) != p {
+			continue
+		}
+		out = append(out, indexedDirEntry{name: path.Base(candidate), entry: e})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out, nil
+}
+
+func (f *indexedFilesystem) Join(elem ...string) string { return path.Join(elem...) }
+
+func (f *indexedFilesystem) Walk(ctx context.Context, root string, fn fs.WalkDirFunc) error {
+	root = f.clean(root)
+	return f.walk(ctx, root, fn)
+}
+
+func (f *indexedFilesystem) walk(ctx context.Context, p string, fn fs.WalkDirFunc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	info, err := f.Lstat(p)
+	var walkErr error
+	if err != nil {
+		walkErr = fn(p, nil, err)
+	} else {
+		walkErr = fn(p, indexedDirEntry{name: info.Name(), entry: f.entries[p]}, nil)
+	}
+	if walkErr != nil || err != nil {
+		if walkErr == fs.SkipDir {
+			return nil
+		}
+		return walkErr
+	}
+
+	if !f.entries[p].mode.IsDir() {
+		return nil
+	}
+	children, err := f.ReadDir(p)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		childPath := path.Join(p, child.Name())
+		if err := f.walk(ctx, childPath, fn); err != nil {
+			if err == fs.SkipDir {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+type indexedFileInfo struct {
+	name  string
+	entry indexedEntry
+}
+
+func (i indexedFileInfo) Name() string       { return i.name }
+func (i indexedFileInfo) Size() int64        { return i.entry.size }
+func (i indexedFileInfo) Mode() fs.FileMode  { return i.entry.mode }
+func (i indexedFileInfo) ModTime() time.Time { return i.entry.modTime }
+func (i indexedFileInfo) IsDir() bool        { return i.entry.mode.IsDir() }
+func (i indexedFileInfo) Sys() any           { return nil }
+
+type indexedDirEntry struct {
+	name  string
+	entry indexedEntry
+}
+
+func (d indexedDirEntry) Name() string      { return d.name }
+func (d indexedDirEntry) IsDir() bool       { return d.entry.mode.IsDir() }
+func (d indexedDirEntry) Type() fs.FileMode { return d.entry.mode.Type() }
+func (d indexedDirEntry) Info() (os.FileInfo, error) {
+	return indexedFileInfo{name: d.name, entry: d.entry}, nil
+}
@@ -0,0 +1,63 @@
+package scan
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"time"
+)
+
+// InfiniteFilesystem is a pathological Filesystem used to exercise
+// cancellation: every directory contains exactly one file and one
+// subdirectory, so a Walk over it never terminates on its own. It only
+// makes sense paired with a context that gets cancelled.
+type InfiniteFilesystem struct {
+	fileSize int64
+}
+
+func NewInfiniteFilesystem(fileSize int64) *InfiniteFilesystem {
+	return &InfiniteFilesystem{fileSize: fileSize}
+}
+
+func (i *InfiniteFilesystem) Lstat(p string) (os.FileInfo, error) {
+	p = path.Clean("/" + p)
+	if path.Base(p) == "leaf" {
+		return indexedFileInfo{name: "leaf", entry: indexedEntry{size: i.fileSize, modTime: time.Unix(0, 0)}}, nil
+	}
+	return indexedFileInfo{name: path.Base(p), entry: indexedEntry{mode: fs.ModeDir, modTime: time.Unix(0, 0)}}, nil
+}
+
+func (i *InfiniteFilesystem) ReadDir(p string) ([]os.DirEntry, error) {
+	return []os.DirEntry{
+		indexedDirEntry{name: "leaf", entry: indexedEntry{size: i.fileSize}},
+		indexedDirEntry{name: "next", entry: indexedEntry{mode: fs.ModeDir}},
+	}, nil
+}
+
+func (i *InfiniteFilesystem) Join(elem ...string) string { return path.Join(elem...) }
+
+func (i *InfiniteFilesystem) Walk(ctx context.Context, root string, fn fs.WalkDirFunc) error {
+	p := path.Clean("/" + root)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		info, err := i.Lstat(p)
+		if err := fn(p, indexedDirEntry{name: info.Name(), entry: indexedEntry{mode: info.Mode(), size: info.Size()}}, err); err != nil {
+			return err
+		}
+		children, _ := i.ReadDir(p)
+		for _, c := range children {
+			if c.Name() == "leaf" {
+				leafPath := path.Join(p, c.Name())
+				leafInfo, _ := c.Info()
+				if err := fn(leafPath, c, nil); err != nil {
+					return err
+				}
+				_ = leafInfo
+			}
+		}
+		p = path.Join(p, "next")
+	}
+}
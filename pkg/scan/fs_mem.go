@@ -0,0 +1,30 @@
+package scan
+
+import (
+	"io/fs"
+	"time"
+)
+
+// MemFilesystem is a Filesystem held entirely in memory, for tests that
+// need deterministic, disk-free trees (including large ones that would
+// be impractical to build on a real filesystem).
+type MemFilesystem struct {
+	*indexedFilesystem
+}
+
+func NewMemFilesystem() *MemFilesystem {
+	return &MemFilesystem{indexedFilesystem: newIndexedFilesystem()}
+}
+
+// AddFile registers a file at path with the given size and mode time,
+// creating any missing parent directories.
+func (m *MemFilesystem) AddFile(path string, size int64, modTime time.Time) {
+	m.put(path, indexedEntry{size: size, mode: 0, modTime: modTime})
+}
+
+// AddDir registers an explicit directory at path (parents are created
+// implicitly by AddFile/AddDir already, so this is only needed for
+// empty directories).
+func (m *MemFilesystem) AddDir(path string, modTime time.Time) {
+	m.put(path, indexedEntry{mode: fs.ModeDir, modTime: modTime})
+}
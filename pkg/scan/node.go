@@ -0,0 +1,210 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Node is one file or directory in a (possibly depth-limited) recursive
+// scan, with Children populated for directories expanded by ScanTree.
+type Node struct {
+	Name     string
+	Path     string
+	Size     int64
+	Type     string // "file" or "dir"
+	Count    int    // total file count under this node (1 for a file)
+	Children []*Node
+}
+
+// Flatten returns every node in the tree rooted at n, including n
+// itself, in no particular order. Callers typically sort the result by
+// Size to implement "top N largest anywhere in the tree".
+func (n *Node) Flatten() []*Node {
+	var all []*Node
+	var walk func(*Node)
+	walk = func(cur *Node) {
+		all = append(all, cur)
+		for _, c := range cur.Children {
+			walk(c)
+		}
+	}
+	walk(n)
+	return all
+}
+
+// ScanTree scans root like Scan, but recursively expands subdirectories
+// into Node.Children up to maxDepth levels (maxDepth <= 0 means
+// unlimited, for "top N anywhere in the tree" style queries). Every
+// directory in the tree is read exactly once: a directory still being
+// expanded has its aggregate Size computed by summing the very same
+// recursive build that produces its Children, instead of sizing it with
+// one walk and then separately re-walking it to discover those
+// children.
+func (s *Scanner) ScanTree(ctx context.Context, root string, opts Options, maxDepth int) (*Node, error) {
+	info, err := s.FS.Lstat(root)
+	if err != nil {
+		return nil, err
+	}
+	node := &Node{Name: info.Name(), Path: root, Type: "dir"}
+	sem := make(chan struct{}, defaultConcurrency(s.Concurrency))
+	size, count, err := s.fillTree(ctx, node, opts, maxDepth, sem)
+	if err != nil {
+		return nil, err
+	}
+	node.Size = size
+	node.Count = count
+	return node, nil
+}
+
+// fillTree reads node.Path's entries once, sizing files directly and
+// either recursing into subdirectories (while depth allows expansion)
+// or sizing them with a single bounded walk (once expansion stops), and
+// returns the resulting aggregate size and file count for node itself.
+// sem bounds how many directories are being read or walked at once,
+// across the whole tree, the same way Pipeline's semaphore bounds
+// directory descriptors within one Scan.
+func (s *Scanner) fillTree(ctx context.Context, node *Node, opts Options, depth int, sem chan struct{}) (int64, int, error) {
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return 0, 0, ctx.Err()
+	}
+	entries, err := s.FS.ReadDir(node.Path)
+	<-sem
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	type job struct {
+		name  string
+		isDir bool
+	}
+	jobs := make([]job, 0, len(entries))
+	for _, de := range entries {
+		if !opts.ShowAll && strings.HasPrefix(de.Name(), ".") {
+			continue
+		}
+		jobs = append(jobs, job{name: de.Name(), isDir: de.IsDir()})
+	}
+
+	expand := depth != 1
+	nextDepth := depth - 1 // depth <= 0 (unlimited) stays unlimited
+	if depth <= 0 {
+		nextDepth = depth
+	}
+
+	children := make([]*Node, len(jobs))
+	var (
+		mu         sync.Mutex
+		total      int64
+		count      int
+		done       int
+		lastUpdate time.Time
+		cancelErr  error
+		wg         sync.WaitGroup
+	)
+
+	for i, j := range jobs {
+		i, j := i, j
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			path := s.FS.Join(node.Path, j.name)
+			child := &Node{Name: j.name, Path: path}
+
+			var size int64
+			var cnt int
+			var sizeErr error
+
+			if err := ctx.Err(); err != nil {
+				sizeErr = err
+			} else if j.isDir {
+				child.Type = "dir"
+				if opts.OnScanning != nil {
+					opts.OnScanning(j.name)
+				}
+				if expand {
+					size, cnt, sizeErr = s.fillTree(ctx, child, opts, nextDepth, sem)
+				} else {
+					select {
+					case sem <- struct{}{}:
+						size, cnt, sizeErr = sizeDirWith(ctx, s.FS, path, opts.ShowAll)
+						<-sem
+					case <-ctx.Done():
+						sizeErr = ctx.Err()
+					}
+				}
+			} else {
+				child.Type = "file"
+				var info os.FileInfo
+				info, sizeErr = s.FS.Lstat(path)
+				if sizeErr == nil {
+					size = info.Size()
+					cnt = 1
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			if sizeErr != nil {
+				if errors.Is(sizeErr, context.Canceled) || errors.Is(sizeErr, context.DeadlineExceeded) {
+					// Unlike a child we simply can't read, a cancelled
+					// context means the caller wants the whole scan to
+					// stop, not a tree with a hole in it.
+					if cancelErr == nil {
+						cancelErr = sizeErr
+					}
+					return
+				}
+				// A child we can no longer size shouldn't sink the whole
+				// tree; it just stays out of the aggregate.
+				if opts.OnProgress != nil {
+					reportProgress(opts, done, len(jobs), &lastUpdate)
+				}
+				return
+			}
+			child.Size = size
+			child.Count = cnt
+			children[i] = child
+			total += size
+			count += cnt
+			if opts.OnProgress != nil {
+				reportProgress(opts, done, len(jobs), &lastUpdate)
+			}
+			if opts.OnEntry != nil {
+				opts.OnEntry(Entry{Name: j.name, Size: size, Type: child.Type})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if cancelErr != nil {
+		return 0, 0, cancelErr
+	}
+
+	for _, c := range children {
+		if c != nil {
+			node.Children = append(node.Children, c)
+		}
+	}
+	return total, count, nil
+}
+
+// reportProgress calls opts.OnProgress at most once every 100ms,
+// matching the throttling Pipeline.Run applies to its own progress
+// callback.
+func reportProgress(opts Options, done, total int, lastUpdate *time.Time) {
+	if now := time.Now(); now.Sub(*lastUpdate) > 100*time.Millisecond {
+		opts.OnProgress(done, total)
+		*lastUpdate = now
+	}
+}
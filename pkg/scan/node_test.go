@@ -0,0 +1,134 @@
+package scan
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// buildNodeTestTree lays out:
+//
+//	/root/a.txt        (10)
+//	/root/sub/b.txt     (20)
+//	/root/sub/deep/c.txt (30)
+func buildNodeTestTree() *MemFilesystem {
+	fsys := NewMemFilesystem()
+	fsys.AddFile("/root/a.txt", 10, time.Unix(0, 0))
+	fsys.AddFile("/root/sub/b.txt", 20, time.Unix(0, 0))
+	fsys.AddFile("/root/sub/deep/c.txt", 30, time.Unix(0, 0))
+	return fsys
+}
+
+func findChild(node *Node, name string) *Node {
+	for _, c := range node.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestScanTreeDepth1(t *testing.T) {
+	s := NewScanner(buildNodeTestTree())
+	tree, err := s.ScanTree(context.Background(), "/root", Options{}, 1)
+	if err != nil {
+		t.Fatalf("ScanTree: %v", err)
+	}
+
+	if got, want := tree.Size, int64(60); got != want {
+		t.Errorf("tree.Size = %d, want %d", got, want)
+	}
+	if got, want := tree.Count, 3; got != want {
+		t.Errorf("tree.Count = %d, want %d", got, want)
+	}
+
+	sub := findChild(tree, "sub")
+	if sub == nil {
+		t.Fatalf("no 'sub' child in %+v", tree.Children)
+	}
+	if got, want := sub.Size, int64(50); got != want {
+		t.Errorf("sub.Size = %d, want %d (aggregate of its subtree)", got, want)
+	}
+	if len(sub.Children) != 0 {
+		t.Errorf("depth 1: sub.Children = %+v, want none (not expanded)", sub.Children)
+	}
+}
+
+func TestScanTreeDepth2(t *testing.T) {
+	s := NewScanner(buildNodeTestTree())
+	tree, err := s.ScanTree(context.Background(), "/root", Options{}, 2)
+	if err != nil {
+		t.Fatalf("ScanTree: %v", err)
+	}
+
+	sub := findChild(tree, "sub")
+	if sub == nil {
+		t.Fatalf("no 'sub' child in %+v", tree.Children)
+	}
+	if got, want := sub.Size, int64(50); got != want {
+		t.Errorf("sub.Size = %d, want %d", got, want)
+	}
+
+	deep := findChild(sub, "deep")
+	if deep == nil {
+		t.Fatalf("no 'deep' child in %+v", sub.Children)
+	}
+	if got, want := deep.Size, int64(30); got != want {
+		t.Errorf("deep.Size = %d, want %d (aggregate, even though not itself expanded)", got, want)
+	}
+	if len(deep.Children) != 0 {
+		t.Errorf("depth 2: deep.Children = %+v, want none (depth exhausted)", deep.Children)
+	}
+
+	b := findChild(sub, "b.txt")
+	if b == nil || b.Size != 20 {
+		t.Errorf("sub's b.txt = %+v, want size 20", b)
+	}
+}
+
+func TestScanTreeUnlimitedDepth(t *testing.T) {
+	s := NewScanner(buildNodeTestTree())
+	tree, err := s.ScanTree(context.Background(), "/root", Options{}, -1)
+	if err != nil {
+		t.Fatalf("ScanTree: %v", err)
+	}
+
+	sub := findChild(tree, "sub")
+	if sub == nil {
+		t.Fatalf("no 'sub' child in %+v", tree.Children)
+	}
+	deep := findChild(sub, "deep")
+	if deep == nil {
+		t.Fatalf("no 'deep' child in %+v", sub.Children)
+	}
+	c := findChild(deep, "c.txt")
+	if c == nil || c.Size != 30 {
+		t.Fatalf("deep's c.txt = %+v, want size 30", c)
+	}
+
+	// Every node anywhere in the tree should be reachable via Flatten,
+	// which is what --top relies on to find the largest node at any depth.
+	all := tree.Flatten()
+	names := make(map[string]bool, len(all))
+	for _, n := range all {
+		names[n.Name] = true
+	}
+	for _, want := range []string{"root", "a.txt", "sub", "b.txt", "deep", "c.txt"} {
+		if !names[want] {
+			t.Errorf("Flatten() missing node %q; got %v", want, names)
+		}
+	}
+}
+
+func TestScanTreeCancellation(t *testing.T) {
+	fsys := NewInfiniteFilesystem(1)
+	s := NewScanner(fsys)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := s.ScanTree(ctx, "/", Options{}, -1)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("ScanTree on an infinite tree returned %v, want context.DeadlineExceeded", err)
+	}
+}
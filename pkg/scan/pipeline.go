@@ -0,0 +1,268 @@
+package scan
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Pipeline is the reusable producer/consumer backbone of a scan: a
+// walker goroutine emits each direct child of root, a bounded pool of
+// sizer goroutines sizes directories (or stats files), and a collector
+// merges the results into a Result. A semaphore caps how many directory
+// descriptors are open at once, so a wide tree can't exhaust file
+// descriptors the way one goroutine per top-level directory could.
+type Pipeline struct {
+	FS Filesystem
+
+	// Concurrency bounds in-flight directory sizing. Zero uses the
+	// default (NumCPU, capped at 8).
+	Concurrency int
+}
+
+// NewPipeline returns a Pipeline backed by fsys. A nil fsys defaults to
+// OSFilesystem{}; concurrency <= 0 uses the default.
+func NewPipeline(fsys Filesystem, concurrency int) *Pipeline {
+	if fsys == nil {
+		fsys = OSFilesystem{}
+	}
+	return &Pipeline{FS: fsys, Concurrency: concurrency}
+}
+
+func (p *Pipeline) concurrency() int {
+	return defaultConcurrency(p.Concurrency)
+}
+
+// defaultConcurrency returns c if positive, otherwise the package
+// default (NumCPU, capped at 8). Shared by Pipeline and the tree
+// builder in node.go so both bound in-flight directory work the same
+// way.
+func defaultConcurrency(c int) int {
+	if c > 0 {
+		return c
+	}
+	n := runtime.NumCPU()
+	if n > 8 {
+		n = 8
+	}
+	return n
+}
+
+// childJob is one direct child of the scanned root, as emitted by the
+// walker stage.
+type childJob struct {
+	name  string
+	isDir bool
+}
+
+// sizedResult is what the sizer stage produces for one childJob.
+type sizedResult struct {
+	entry Entry
+	count int
+	skip  bool // non-fatal: e.g. a file we couldn't stat
+	err   error
+}
+
+// Run walks root's immediate children through the pipeline and returns
+// the aggregated Result. It propagates the first error seen (including
+// ctx cancellation) and otherwise returns entries sorted largest-first.
+func (p *Pipeline) Run(ctx context.Context, root string, opts Options) (*Result, error) {
+	dirEntries, err := p.FS.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make(chan childJob, len(dirEntries))
+	results := make(chan sizedResult, len(dirEntries))
+	sem := make(chan struct{}, p.concurrency())
+
+	// walker: emits a job per direct child, applying the dotfile filter.
+	go func() {
+		defer close(jobs)
+		for _, de := range dirEntries {
+			if !opts.ShowAll && strings.HasPrefix(de.Name(), ".") {
+				continue
+			}
+			select {
+			case jobs <- childJob{name: de.Name(), isDir: de.IsDir()}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// sizer pool: bounded by sem, one slot per in-flight directory scan.
+	var wg sync.WaitGroup
+	for i := 0; i < p.concurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				results <- p.size(ctx, sem, root, job, opts)
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// collector: merges sizedResults into the final Result.
+	var entries []Entry
+	var totalSize int64
+	var totalCount int
+	var firstErr error
+	done, total := 0, len(dirEntries)
+	var lastUpdate time.Time
+
+	for r := range results {
+		done++
+		if opts.OnProgress != nil {
+			if now := time.Now(); now.Sub(lastUpdate) > 100*time.Millisecond {
+				opts.OnProgress(done, total)
+				lastUpdate = now
+			}
+		}
+
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			continue
+		}
+		if r.skip {
+			continue
+		}
+		if opts.OnEntry != nil {
+			opts.OnEntry(r.entry)
+		}
+
+		entries = append(entries, r.entry)
+		totalSize += r.entry.Size
+		totalCount += r.count
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Size > entries[j].Size })
+	return &Result{Entries: entries, TotalSize: totalSize, TotalCount: totalCount}, nil
+}
+
+func (p *Pipeline) size(ctx context.Context, sem chan struct{}, root string, job childJob, opts Options) sizedResult {
+	if ctx.Err() != nil {
+		return sizedResult{err: ctx.Err()}
+	}
+
+	path := p.FS.Join(root, job.name)
+
+	if !job.isDir {
+		info, err := p.FS.Lstat(path)
+		if err != nil {
+			return sizedResult{skip: true}
+		}
+		return sizedResult{entry: Entry{Name: job.name, Size: info.Size(), Type: "file"}, count: 1}
+	}
+
+	// Acquire a semaphore slot before opening the directory, bounding how
+	// many directory descriptors are in flight at once.
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return sizedResult{err: ctx.Err()}
+	}
+	defer func() { <-sem }()
+
+	if opts.OnScanning != nil {
+		opts.OnScanning(job.name)
+	}
+
+	size, count, err := sizeDirWith(ctx, p.FS, path, opts.ShowAll)
+	if err != nil {
+		return sizedResult{err: err}
+	}
+	return sizedResult{entry: Entry{Name: job.name, Size: size, Type: "dir"}, count: count}
+}
+
+// sizeDirWith walks root and returns its total file size and file
+// count, checking ctx at every step so a cancelled scan unwinds
+// promptly instead of finishing the subtree first.
+func sizeDirWith(ctx context.Context, fsys Filesystem, root string, showAll bool) (int64, int, error) {
+	if _, ok := fsys.(OSFilesystem); ok {
+		// The real OS filesystem can use the getdents-based fast path,
+		// which skips the extra lstat WalkDir does for directories and
+		// symlinks.
+		return sizeDirFast(ctx, root, showAll)
+	}
+
+	var total int64
+	count := 0
+	err := fsys.Walk(ctx, root, func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return nil
+		}
+
+		name := filepath.Base(path)
+		if !showAll && len(name) > 0 && name[0] == '.' {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		if d.Type()&os.ModeSymlink != 0 {
+			count++
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		total += info.Size()
+		count++
+		return nil
+	})
+	return total, count, err
+}
+
+func sizeDirFast(ctx context.Context, root string, showAll bool) (int64, int, error) {
+	var total int64
+	count := 0
+	err := WalkFast(ctx, root, func(entry FastEntry) error {
+		if !showAll && len(entry.Name) > 0 && entry.Name[0] == '.' {
+			if entry.IsDir {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if entry.IsDir {
+			return nil
+		}
+
+		if entry.IsSymlink {
+			count++
+			return nil
+		}
+
+		total += entry.Size
+		count++
+		return nil
+	})
+	return total, count, err
+}
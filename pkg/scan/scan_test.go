@@ -0,0 +1,160 @@
+package scan
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScanMemFilesystem(t *testing.T) {
+	fsys := NewMemFilesystem()
+	fsys.AddFile("/root/a.txt", 10, time.Unix(0, 0))
+	fsys.AddFile("/root/sub/b.txt", 20, time.Unix(0, 0))
+	fsys.AddFile("/root/sub/c.txt", 30, time.Unix(0, 0))
+	fsys.AddDir("/root/empty", time.Unix(0, 0))
+
+	s := NewScanner(fsys)
+	result, err := s.Scan(context.Background(), "/root", Options{})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+
+	if got, want := result.TotalSize, int64(60); got != want {
+		t.Errorf("TotalSize = %d, want %d", got, want)
+	}
+	if got, want := result.TotalCount, 3; got != want {
+		t.Errorf("TotalCount = %d, want %d", got, want)
+	}
+	if len(result.Entries) != 3 {
+		t.Fatalf("len(Entries) = %d, want 3", len(result.Entries))
+	}
+	// Entries are sorted largest-first; "sub" (50) should lead "a.txt" (10).
+	if result.Entries[0].Name != "sub" || result.Entries[0].Size != 50 {
+		t.Errorf("Entries[0] = %+v, want sub/50", result.Entries[0])
+	}
+}
+
+func TestScanMemFilesystemShowAll(t *testing.T) {
+	fsys := NewMemFilesystem()
+	fsys.AddFile("/root/a.txt", 10, time.Unix(0, 0))
+	fsys.AddFile("/root/.hidden", 5, time.Unix(0, 0))
+
+	s := NewScanner(fsys)
+
+	result, err := s.Scan(context.Background(), "/root", Options{})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if got, want := result.TotalSize, int64(10); got != want {
+		t.Errorf("without ShowAll: TotalSize = %d, want %d", got, want)
+	}
+
+	result, err = s.Scan(context.Background(), "/root", Options{ShowAll: true})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if got, want := result.TotalSize, int64(15); got != want {
+		t.Errorf("with ShowAll: TotalSize = %d, want %d", got, want)
+	}
+}
+
+func TestScanTarFilesystem(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.tar")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	tw := tar.NewWriter(f)
+	writeTarFile(t, tw, "a.txt", 10)
+	writeTarFile(t, tw, "sub/b.txt", 20)
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tw.Close: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("f.Close: %v", err)
+	}
+
+	fsys, err := OpenTarFilesystem(path)
+	if err != nil {
+		t.Fatalf("OpenTarFilesystem: %v", err)
+	}
+
+	s := NewScanner(fsys)
+	result, err := s.Scan(context.Background(), "/", Options{})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if got, want := result.TotalSize, int64(30); got != want {
+		t.Errorf("TotalSize = %d, want %d", got, want)
+	}
+}
+
+func writeTarFile(t *testing.T, tw *tar.Writer, name string, size int64) {
+	t.Helper()
+	if err := tw.WriteHeader(&tar.Header{Name: name, Size: size, Mode: 0o644}); err != nil {
+		t.Fatalf("WriteHeader(%s): %v", name, err)
+	}
+	if _, err := tw.Write(make([]byte, size)); err != nil {
+		t.Fatalf("Write(%s): %v", name, err)
+	}
+}
+
+func TestScanZipFilesystem(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	zw := zip.NewWriter(f)
+	writeZipFile(t, zw, "a.txt", 10)
+	writeZipFile(t, zw, "sub/b.txt", 20)
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zw.Close: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("f.Close: %v", err)
+	}
+
+	fsys, err := OpenZipFilesystem(path)
+	if err != nil {
+		t.Fatalf("OpenZipFilesystem: %v", err)
+	}
+	defer fsys.Close()
+
+	s := NewScanner(fsys)
+	result, err := s.Scan(context.Background(), "/", Options{})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if got, want := result.TotalSize, int64(30); got != want {
+		t.Errorf("TotalSize = %d, want %d", got, want)
+	}
+}
+
+func writeZipFile(t *testing.T, zw *zip.Writer, name string, size int64) {
+	t.Helper()
+	w, err := zw.Create(name)
+	if err != nil {
+		t.Fatalf("Create(%s): %v", name, err)
+	}
+	if _, err := w.Write(make([]byte, size)); err != nil {
+		t.Fatalf("Write(%s): %v", name, err)
+	}
+}
+
+func TestScanCancellation(t *testing.T) {
+	fsys := NewInfiniteFilesystem(1)
+	s := NewScanner(fsys)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := s.Scan(ctx, "/", Options{})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Scan on an infinite tree returned %v, want context.DeadlineExceeded", err)
+	}
+}
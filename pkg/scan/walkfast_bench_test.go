@@ -0,0 +1,83 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildSyntheticTree creates dirs*filesPerDir files spread across a
+// directory tree, returning its root.
+func buildSyntheticTree(b *testing.B, dirs, filesPerDir int) string {
+	b.Helper()
+	root := b.TempDir()
+	for d := 0; d < dirs; d++ {
+		dirPath := filepath.Join(root, fmt.Sprintf("dir%d", d))
+		if err := os.Mkdir(dirPath, 0o755); err != nil {
+			b.Fatal(err)
+		}
+		for f := 0; f < filesPerDir; f++ {
+			name := filepath.Join(dirPath, fmt.Sprintf("file%d", f))
+			if err := os.WriteFile(name, []byte("x"), 0o644); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	return root
+}
+
+// benchTreeSize picks the synthetic tree's dirs/filesPerDir. -short
+// keeps CI runs fast with a 5,000-file tree; the default (no -short) is
+// the full 500k-file comparison from the original proposal, which takes
+// minutes to build and run.
+func benchTreeSize(b *testing.B) (dirs, filesPerDir int) {
+	b.Helper()
+	if testing.Short() {
+		return 50, 100 // 5,000 files
+	}
+	return 500, 1000 // 500,000 files
+}
+
+func BenchmarkWalkFast(b *testing.B) {
+	dirs, filesPerDir := benchTreeSize(b)
+	root := buildSyntheticTree(b, dirs, filesPerDir)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var total int64
+		err := WalkFast(ctx, root, func(e FastEntry) error {
+			total += e.Size
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWalkDir(b *testing.B) {
+	dirs, filesPerDir := benchTreeSize(b)
+	root := buildSyntheticTree(b, dirs, filesPerDir)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var total int64
+		err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			total += info.Size()
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+//go:build linux
+
+package scan
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// Linux dirent64 type bits (see <dirent.h>). d_type lets us classify an
+// entry straight from the getdents64 buffer, with no per-entry lstat.
+const (
+	dtUnknown = 0
+	dtDir     = 4
+	dtReg     = 8
+	dtLnk     = 10
+)
+
+// FastEntry is one entry yielded by WalkFast.
+type FastEntry struct {
+	Path      string
+	Name      string
+	IsDir     bool
+	IsSymlink bool
+	// Size is populated for regular files only; WalkFast stats those but
+	// skips the lstat entirely for directories and symlinks.
+	Size int64
+}
+
+// WalkFast walks root using getdents64 directly instead of
+// ReadDir+Lstat, so directories and symlinks cost one syscall each
+// rather than two. Only regular files are stat'd, to learn their size,
+// plus any entry whose type getdents64 doesn't report as dir, regular
+// file or symlink.
+// fn is called depth-first, parent before children; returning fs.SkipDir
+// from fn for a directory entry skips its subtree.
+func WalkFast(ctx context.Context, root string, fn func(FastEntry) error) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		return err
+	}
+	rootEntry := FastEntry{Path: root, Name: filepath.Base(root), IsDir: info.IsDir()}
+	if err := fn(rootEntry); err != nil {
+		if err == fs.SkipDir {
+			return nil
+		}
+		return err
+	}
+	if !rootEntry.IsDir {
+		return nil
+	}
+	return walkFastDir(ctx, root, fn)
+}
+
+func walkFastDir(ctx context.Context, dir string, fn func(FastEntry) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fd, err := syscall.Open(dir, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	buf := make([]byte, 32*1024)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n, err := syscall.ReadDirent(fd, buf)
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			return nil
+		}
+
+		data := buf[:n]
+		for len(data) > 0 {
+			if len(data) < 19 {
+				break
+			}
+			reclen := *(*uint16)(unsafe.Pointer(&data[16]))
+			if reclen == 0 || int(reclen) > len(data) {
+				break
+			}
+			typ := data[18]
+			nameBytes := data[19:reclen]
+			if i := bytes.IndexByte(nameBytes, 0); i >= 0 {
+				nameBytes = nameBytes[:i]
+			}
+			name := string(nameBytes)
+			data = data[reclen:]
+
+			if name == "." || name == ".." {
+				continue
+			}
+
+			path := filepath.Join(dir, name)
+			entry := FastEntry{Path: path, Name: name}
+
+			switch typ {
+			case dtDir:
+				entry.IsDir = true
+			case dtLnk:
+				entry.IsSymlink = true
+			case dtReg:
+				if info, err := os.Lstat(path); err == nil {
+					entry.Size = info.Size()
+				}
+			default:
+				// dtUnknown or an exotic type bit: fall back to a real
+				// stat to classify it correctly.
+				info, err := os.Lstat(path)
+				if err != nil {
+					continue
+				}
+				entry.IsDir = info.IsDir()
+				entry.IsSymlink = info.Mode()&os.ModeSymlink != 0
+				if !entry.IsDir && !entry.IsSymlink {
+					entry.Size = info.Size()
+				}
+			}
+
+			err := fn(entry)
+			if err == fs.SkipDir {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			if entry.IsDir {
+				if err := walkFastDir(ctx, path, fn); err != nil {
+					if err == fs.SkipDir {
+						continue
+					}
+					return err
+				}
+			}
+		}
+	}
+}
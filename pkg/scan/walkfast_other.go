@@ -0,0 +1,44 @@
+//go:build !linux
+
+package scan
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FastEntry is one entry yielded by WalkFast.
+type FastEntry struct {
+	Path      string
+	Name      string
+	IsDir     bool
+	IsSymlink bool
+	Size      int64
+}
+
+// WalkFast is the portable fallback for platforms without a getdents64
+// fast path (see walkfast_linux.go): it's built on filepath.WalkDir, so
+// it doesn't save any syscalls, but keeps the same API available
+// everywhere sizer runs.
+func WalkFast(ctx context.Context, root string, fn func(FastEntry) error) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			return err
+		}
+
+		entry := FastEntry{Path: path, Name: d.Name(), IsDir: d.IsDir()}
+		if d.Type()&os.ModeSymlink != 0 {
+			entry.IsSymlink = true
+		} else if !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				entry.Size = info.Size()
+			}
+		}
+		return fn(entry)
+	})
+}
@@ -0,0 +1,95 @@
+package scan
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildWalkFastTree lays out a small tree exercising every FastEntry
+// kind WalkFast needs to classify: a regular file, a subdirectory with
+// its own file, and a symlink.
+func buildWalkFastTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "sub", "b.txt"), []byte("worldwide"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "a.txt"), filepath.Join(root, "link")); err != nil {
+		t.Skipf("symlinks unsupported on this platform: %v", err)
+	}
+	return root
+}
+
+func TestWalkFast(t *testing.T) {
+	root := buildWalkFastTree(t)
+
+	got := make(map[string]FastEntry)
+	err := WalkFast(context.Background(), root, func(e FastEntry) error {
+		got[e.Path] = e
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkFast: %v", err)
+	}
+
+	rootEntry, ok := got[root]
+	if !ok || !rootEntry.IsDir {
+		t.Fatalf("root entry missing or not a dir: %+v", rootEntry)
+	}
+
+	aPath := filepath.Join(root, "a.txt")
+	a, ok := got[aPath]
+	if !ok || a.IsDir || a.IsSymlink || a.Size != 5 {
+		t.Fatalf("a.txt entry wrong: %+v", a)
+	}
+
+	subPath := filepath.Join(root, "sub")
+	sub, ok := got[subPath]
+	if !ok || !sub.IsDir {
+		t.Fatalf("sub entry missing or not a dir: %+v", sub)
+	}
+
+	bPath := filepath.Join(root, "sub", "b.txt")
+	b, ok := got[bPath]
+	if !ok || b.IsDir || b.IsSymlink || b.Size != 9 {
+		t.Fatalf("sub/b.txt entry wrong: %+v", b)
+	}
+
+	linkPath := filepath.Join(root, "link")
+	link, ok := got[linkPath]
+	if !ok || !link.IsSymlink || link.IsDir {
+		t.Fatalf("link entry wrong: %+v", link)
+	}
+}
+
+func TestWalkFastSkipDir(t *testing.T) {
+	root := buildWalkFastTree(t)
+
+	var visited []string
+	err := WalkFast(context.Background(), root, func(e FastEntry) error {
+		visited = append(visited, e.Path)
+		if e.Path == filepath.Join(root, "sub") {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkFast: %v", err)
+	}
+
+	bPath := filepath.Join(root, "sub", "b.txt")
+	for _, p := range visited {
+		if p == bPath {
+			t.Fatalf("fs.SkipDir on %q did not skip its contents; saw %q", filepath.Join(root, "sub"), bPath)
+		}
+	}
+}